@@ -4,26 +4,106 @@
 package main
 
 import (
+	"bytes"
 	"embed"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"text/template"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
 	pkg "github.com/tetrateio/crdoc/pkg/builder"
 )
 
 const (
-	outputOption    = "output"
-	templateOption  = "template"
-	resourcesOption = "resources"
-	tocOption       = "toc"
+	outputOption         = "output"
+	templateOption       = "template"
+	resourcesOption      = "resources"
+	tocOption            = "toc"
+	outputTemplateOption = "output-template"
+	outputFormatOption   = "output-format"
+
+	fromClusterOption   = "from-cluster"
+	kubeconfigOption    = "kubeconfig"
+	contextOption       = "context"
+	namespaceOption     = "namespace"
+	selectorOption      = "selector"
+	fieldSelectorOption = "field-selector"
+	groupPrefixOption   = "group-prefix"
+
+	openapiOption           = "openapi"
+	openapiOnlyGroupsOption = "openapi-only-groups"
+
+	emitOption = "emit"
 )
 
+// defaultOutputTemplate preserves the historical behavior of one output
+// file per CRD group, including the dot-to-dash rewrite crdoc has always
+// applied to the group name (e.g. "tsb.tetrate.io" -> "tsb-tetrate-io.md").
+const defaultOutputTemplate = `{{.Group | replace "." "-"}}.md`
+
+// outputPathData is the data made available to the --output-template
+// template when evaluating the output path for a single CRD.
+type outputPathData struct {
+	Group      string
+	Version    string
+	Kind       string
+	ShortNames []string
+	Scope      string
+}
+
+// outputTemplateFuncs are the functions available to --output-template,
+// beyond the standard text/template builtins.
+var outputTemplateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+}
+
+// newOutputTemplate parses an --output-template value with the funcs it
+// exposes (lower, upper, replace).
+func newOutputTemplate(tmpl string) (*template.Template, error) {
+	parsed, err := template.New("output-template").Funcs(outputTemplateFuncs).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("parsing --output-template: %w", err)
+	}
+	return parsed, nil
+}
+
+// evalOutputTemplate evaluates tmpl for a single CRD and returns the
+// resulting output path, cleaned but not yet validated against --output.
+func evalOutputTemplate(tmpl *template.Template, crd apiextensionsv1.CustomResourceDefinition) (string, error) {
+	data := outputPathData{
+		Group:      crd.Spec.Group,
+		Kind:       crd.Spec.Names.Kind,
+		ShortNames: crd.Spec.Names.ShortNames,
+		Scope:      string(crd.Spec.Scope),
+	}
+	if len(crd.Spec.Versions) > 0 {
+		data.Version = crd.Spec.Versions[0].Name
+		for _, v := range crd.Spec.Versions {
+			if v.Storage {
+				data.Version = v.Name
+				break
+			}
+		}
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("evaluating --output-template for %s/%s: %w", crd.Spec.Group, crd.Spec.Names.Kind, err)
+	}
+	return filepath.Clean(rendered.String()), nil
+}
+
 //go:embed templates/*
 var builtinTemplates embed.FS
 
@@ -45,6 +125,24 @@ func RootCmd() *cobra.Command {
 
   # Use a Table of Contents to filter and order CRDs
   crdoc --resources example/crds --output example/output.md --toc example/toc.yaml
+
+  # Split output per CRD kind instead of one file per group
+  crdoc --resources example/crds --output example/docs --output-template '{{.Group}}/{{.Kind | lower}}.md'
+
+  # Document the CRDs installed on the current cluster instead of a local checkout
+  crdoc --from-cluster --selector app.kubernetes.io/part-of=istio --output example/output.md
+
+  # Generate a Hugo-ready site, with frontmatter and a section index per group
+  crdoc --resources example/crds --output example/site/content --output-format hugo
+
+  # Render CRDs alongside built-in types described by a swagger.json
+  crdoc --resources example/crds --openapi example/swagger.json --output example/output.md
+
+  # Use a sectioned toc.yaml to group CRDs from several api groups into one file
+  crdoc --resources example/crds --output example/docs --toc example/sections.yaml
+
+  # Also emit a JSON Schema per CRD version and a self-contained HTML page
+  crdoc --resources example/crds --output example/output.md --emit markdown,jsonschema,html
 `,
 		SilenceErrors: true,
 		SilenceUsage:  true,
@@ -57,59 +155,199 @@ func RootCmd() *cobra.Command {
 			templateOptionValue := viper.GetString(templateOption)
 			resourcesOptionValue := viper.GetString(resourcesOption)
 			tocOptionValue := viper.GetString(tocOption)
+			outputTemplateOptionValue := viper.GetString(outputTemplateOption)
+			outputFormatOptionValue := viper.GetString(outputFormatOption)
+			fromClusterOptionValue := viper.GetBool(fromClusterOption)
+			openapiOptionValue := viper.GetStringSlice(openapiOption)
+			openapiOnlyGroupsOptionValue := viper.GetStringSlice(openapiOnlyGroupsOption)
 
-			crds, err := pkg.LoadCRDs(resourcesOptionValue)
+			siteRenderer, err := pkg.NewSiteRenderer(outputFormatOptionValue, builtinTemplates)
 			if err != nil {
 				return err
 			}
 
+			var source pkg.CRDSource
+			switch {
+			case fromClusterOptionValue:
+				source = pkg.ClusterSource{
+					Kubeconfig:    viper.GetString(kubeconfigOption),
+					Context:       viper.GetString(contextOption),
+					Namespace:     viper.GetString(namespaceOption),
+					LabelSelector: viper.GetString(selectorOption),
+					FieldSelector: viper.GetString(fieldSelectorOption),
+					GroupPrefix:   viper.GetString(groupPrefixOption),
+				}
+			case strings.HasPrefix(resourcesOptionValue, "http://"), strings.HasPrefix(resourcesOptionValue, "https://"):
+				source = pkg.URLSource{URL: resourcesOptionValue}
+			case resourcesOptionValue == "":
+				if len(openapiOptionValue) == 0 {
+					return fmt.Errorf("one of --%s, --%s or --%s is required", resourcesOption, fromClusterOption, openapiOption)
+				}
+			default:
+				info, err := os.Stat(resourcesOptionValue)
+				if err != nil {
+					return err
+				}
+				if info.IsDir() {
+					source = pkg.DirSource{Path: resourcesOptionValue}
+				} else {
+					source = pkg.FileSource{Path: resourcesOptionValue}
+				}
+			}
+
+			var crds []apiextensionsv1.CustomResourceDefinition
+			if source != nil {
+				crds, err = source.Load()
+				if err != nil {
+					return err
+				}
+			}
+
+			if len(openapiOptionValue) > 0 {
+				openapiCRDs, err := (pkg.OpenAPISource{Paths: openapiOptionValue, OnlyGroups: openapiOnlyGroupsOptionValue}).Load()
+				if err != nil {
+					return err
+				}
+				crds = append(crds, openapiCRDs...)
+			}
+
 			// create dirs if needed
 			err = os.MkdirAll(filepath.Dir(outputOptionValue), os.ModePerm)
 			if err != nil {
 				return err
 			}
 
+			outputTmpl, err := newOutputTemplate(outputTemplateOptionValue)
+			if err != nil {
+				return err
+			}
+
+			tocSections, err := pkg.LoadTOCSections(tocOptionValue)
+			if err != nil {
+				return err
+			}
+
+			crdEmitters, docEmitters, keepMarkdown, err := pkg.NewEmitters(viper.GetStringSlice(emitOption), builtinTemplates)
+			if err != nil {
+				return err
+			}
+
 			builders := make(map[string]*pkg.ModelBuilder)
+			builderCRDs := make(map[string][]apiextensionsv1.CustomResourceDefinition)
+			sectionPages := make(map[string][]string)
+			sectionMeta := make(map[string]pkg.TOCSection)
 			sort.Slice(crds, func(i, j int) bool {
 				return crds[i].Spec.Group < crds[j].Spec.Group
 			})
 			for _, crd := range crds {
-				group := crd.Spec.Group
-				if group == "tsb.tetrate.io" {
-					model, err := pkg.LoadModel(tocOptionValue)
-					if err != nil {
-						return err
+				var section *pkg.TOCSection
+				for i := range tocSections {
+					if tocSections[i].Matches(crd) {
+						section = &tocSections[i]
+						break
 					}
-					output := filepath.Clean(filepath.Join(outputOptionValue, strings.Replace(group, ".", "-", -1), strings.ToLower(crd.Spec.Names.Kind)+".md"))
-					fmt.Printf(output + "\n")
-					builder := pkg.NewModelBuilder(model, tocOptionValue != "", templateOptionValue, output, builtinTemplates)
-					err = builder.Add(crd)
-					if err != nil {
-						return err
+				}
+
+				var rel string
+				templateForCRD := templateOptionValue
+				sectionKey := crd.Spec.Group
+
+				if section != nil {
+					rel = filepath.Clean(section.Output)
+					if section.Template != "" {
+						templateForCRD = section.Template
 					}
-					err = os.MkdirAll(filepath.Dir(output), os.ModePerm)
+					sectionKey = section.Name
+					sectionMeta[sectionKey] = *section
+				} else {
+					rel, err = evalOutputTemplate(outputTmpl, crd)
 					if err != nil {
 						return err
 					}
-					builder.Output()
-					continue
 				}
-				if builders[group] == nil {
-					model, err := pkg.LoadModel(tocOptionValue)
+
+				if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+					return fmt.Errorf("output path %q for %s/%s escapes --output", rel, crd.Spec.Group, crd.Spec.Names.Kind)
+				}
+				output := filepath.Join(outputOptionValue, rel)
+
+				if siteRenderer != nil {
+					found := false
+					for _, page := range sectionPages[sectionKey] {
+						if page == rel {
+							found = true
+							break
+						}
+					}
+					if !found {
+						sectionPages[sectionKey] = append(sectionPages[sectionKey], rel)
+					}
+				}
+
+				if builders[output] == nil {
+					// With the sections TOC schema, CRDs were already
+					// assigned to sections above (CRDs no section matches
+					// fall back to --output-template); LoadModel only
+					// understands the legacy flat toc.yaml, so don't feed
+					// it a sections file.
+					legacyTocPath := tocOptionValue
+					if len(tocSections) > 0 {
+						legacyTocPath = ""
+					}
+
+					model, err := pkg.LoadModel(legacyTocPath)
 					if err != nil {
 						return err
 					}
-					output := filepath.Clean(filepath.Join(outputOptionValue, strings.Replace(group, ".", "-", -1)+".md"))
-					builders[group] = pkg.NewModelBuilder(model, tocOptionValue != "", templateOptionValue, output, builtinTemplates)
+					if err := os.MkdirAll(filepath.Dir(output), os.ModePerm); err != nil {
+						return err
+					}
+					builders[output] = pkg.NewModelBuilder(model, legacyTocPath != "", templateForCRD, output, builtinTemplates)
 				}
-				err = builders[group].Add(crd)
+				err = builders[output].Add(crd)
 				if err != nil {
 					return err
 				}
+				builderCRDs[output] = append(builderCRDs[output], crd)
 			}
 
-			for _, builder := range builders {
+			for output, builder := range builders {
 				builder.Output()
+
+				for _, crd := range builderCRDs[output] {
+					for _, emitter := range crdEmitters {
+						if err := emitter.EmitCRD(crd, output); err != nil {
+							return err
+						}
+					}
+				}
+				for _, emitter := range docEmitters {
+					if err := emitter.EmitDoc(output); err != nil {
+						return err
+					}
+				}
+			}
+
+			// renderSite still needs to read the markdown crdoc just wrote,
+			// so it runs before the markdown cleanup below.
+			if siteRenderer != nil {
+				if err := renderSite(siteRenderer, outputOptionValue, sectionPages, sectionMeta, builderCRDs); err != nil {
+					return err
+				}
+			}
+
+			// When a site format is active, renderSite has already
+			// rewritten these same files in place into the site's pages
+			// (frontmatter prepended, links rewritten) - they are the
+			// site's content, not a markdown byproduct of it, so they
+			// must survive even when "markdown" wasn't itself requested
+			// via --emit.
+			if !keepMarkdown && siteRenderer == nil {
+				for output := range builders {
+					if err := os.Remove(output); err != nil {
+						return err
+					}
+				}
 			}
 
 			if err != nil {
@@ -122,10 +360,24 @@ func RootCmd() *cobra.Command {
 
 	cmd.Flags().StringP(outputOption, "o", "", "Path to output markdown file (required)")
 	_ = cmd.MarkFlagRequired(outputOption)
-	cmd.Flags().StringP(resourcesOption, "r", "", "Path to YAML file or directory containing CustomResourceDefinitions (required)")
-	_ = cmd.MarkFlagRequired(resourcesOption)
+	cmd.Flags().StringP(resourcesOption, "r", "", "Path to YAML file, directory or URL containing CustomResourceDefinitions (required unless --from-cluster or --openapi is set)")
 	cmd.Flags().StringP(templateOption, "t", "markdown.tmpl", "Path to file in a templates directory")
 	cmd.Flags().StringP(tocOption, "c", "", "Path to table of contents YAML file")
+	cmd.Flags().String(outputTemplateOption, defaultOutputTemplate, "Go text/template string evaluated per CRD (fields: .Group, .Version, .Kind, .ShortNames, .Scope; funcs: lower, upper, replace) to produce its output path, relative to --output; CRDs that evaluate to the same path share one file")
+	cmd.Flags().String(outputFormatOption, pkg.FormatPlain, "Site generator to target: plain, hugo, docusaurus or mkdocs. Adds frontmatter and a section index per group, plus a navigation fragment for docusaurus and mkdocs")
+
+	cmd.Flags().Bool(fromClusterOption, false, "List CustomResourceDefinitions from a live Kubernetes API server instead of --resources")
+	cmd.Flags().String(kubeconfigOption, "", "Path to a kubeconfig file, used with --from-cluster (defaults to the standard kubeconfig loading rules)")
+	cmd.Flags().String(contextOption, "", "Kubeconfig context to use, used with --from-cluster")
+	cmd.Flags().String(namespaceOption, "", "Namespace, accepted for parity with other flags but unused: CustomResourceDefinitions are cluster-scoped")
+	cmd.Flags().String(selectorOption, "", "Label selector to filter CustomResourceDefinitions, used with --from-cluster (e.g. app.kubernetes.io/part-of=istio)")
+	cmd.Flags().String(fieldSelectorOption, "", "Field selector to filter CustomResourceDefinitions, used with --from-cluster")
+	cmd.Flags().String(groupPrefixOption, "", "Only keep CustomResourceDefinitions whose group starts with this prefix, used with --from-cluster")
+
+	cmd.Flags().StringSlice(openapiOption, nil, "Path to one or more OpenAPI v2 (swagger.json) or v3 documents describing built-in Kubernetes types to render alongside --resources/--from-cluster")
+	cmd.Flags().StringSlice(openapiOnlyGroupsOption, nil, "Only keep OpenAPI definitions whose x-kubernetes-group-version-kind group is in this list, used with --openapi")
+
+	cmd.Flags().StringSlice(emitOption, []string{pkg.EmitMarkdown}, "Comma-separated output formats to write: markdown, jsonschema, asciidoc, html")
 
 	cobra.OnInitialize(initConfig)
 
@@ -137,6 +389,141 @@ func initConfig() {
 	viper.AutomaticEnv()
 }
 
+// mdLinkTargetPattern matches a markdown link target crdoc's templates may
+// have generated pointing at another local markdown page, e.g.
+// "](other-group.md#some-kind)" - the captured group is the path, with any
+// "#anchor" fragment split off so RewriteLink only sees the path itself.
+var mdLinkTargetPattern = regexp.MustCompile(`\]\(([^)\s]+\.md)(#[^)]*)?\)`)
+
+// rewriteSiteLinks rewrites every local .md link target in content through
+// renderer.RewriteLink, so links between generated pages keep resolving
+// once the site format changes how those pages are served.
+func rewriteSiteLinks(renderer pkg.SiteRenderer, content []byte) []byte {
+	return mdLinkTargetPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		groups := mdLinkTargetPattern.FindSubmatch(match)
+		target, anchor := renderer.RewriteLink(string(groups[1])), string(groups[2])
+		return []byte("](" + target + anchor + ")")
+	})
+}
+
+// renderSite post-processes the plain markdown crdoc already wrote under
+// outputDir into the target site generator's conventions: it prepends
+// frontmatter to each generated page, writes a section index per group
+// directory and, for formats that have one, a site-wide navigation
+// fragment. sectionPages maps each section key (a TOC section name, or a
+// CRD group when no TOC section claimed it) to the paths (relative to
+// outputDir) of the pages it owns; sectionMeta carries the TOC-provided
+// title/weight/description for section keys that came from the TOC;
+// pageCRDs maps each page's output path (outputDir joined with its
+// sectionPages entry) to the CRDs rendered onto it, used to derive
+// frontmatter the page's own metadata doesn't already override.
+func renderSite(renderer pkg.SiteRenderer, outputDir string, sectionPages map[string][]string, sectionMeta map[string]pkg.TOCSection, pageCRDs map[string][]apiextensionsv1.CustomResourceDefinition) error {
+	groups := make([]string, 0, len(sectionPages))
+	for group := range sectionPages {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	sections := make([]pkg.SiteSection, 0, len(groups))
+	for i, group := range groups {
+		meta, hasMeta := sectionMeta[group]
+
+		pages := sectionPages[group]
+		sort.Strings(pages)
+
+		weight := i
+		if hasMeta && meta.Weight != 0 {
+			weight = meta.Weight
+		}
+
+		for _, page := range pages {
+			path := filepath.Join(outputDir, page)
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("rendering site: reading %s: %w", path, err)
+			}
+			content = rewriteSiteLinks(renderer, content)
+
+			data := pkg.FrontmatterData{
+				Title:  strings.TrimSuffix(filepath.Base(page), filepath.Ext(page)),
+				Weight: weight,
+			}
+			// A page with exactly one CRD on it (the common per-kind or
+			// per-version --output-template split) can derive its
+			// description and short-name aliases straight from the CRD;
+			// pages bundling several CRDs have no single Kind/ShortNames
+			// to point aliases at, so are left to TOC-provided metadata.
+			if crds := pageCRDs[path]; len(crds) == 1 {
+				data.Description = fmt.Sprintf("%s (%s)", crds[0].Spec.Names.Kind, crds[0].Spec.Group)
+				data.Aliases = append([]string{}, crds[0].Spec.Names.ShortNames...)
+			}
+			if hasMeta {
+				if meta.Title != "" {
+					data.Title = meta.Title
+				}
+				if meta.Description != "" {
+					data.Description = meta.Description
+				}
+			}
+
+			frontmatter, err := renderer.Frontmatter(data)
+			if err != nil {
+				return err
+			}
+
+			if err := os.WriteFile(path, append([]byte(frontmatter), content...), os.ModePerm); err != nil {
+				return fmt.Errorf("rendering site: writing %s: %w", path, err)
+			}
+		}
+
+		// sectionDir can't be derived from where --output-template happened
+		// to place this group's pages (filepath.Dir(pages[0])): with the
+		// default per-group template, every group's pages sit flat
+		// directly under outputDir, so every group would resolve to the
+		// same directory and overwrite each other's section index. Each
+		// group instead gets its own slug directory for its index,
+		// independent of its pages' actual location.
+		sectionDir := filepath.Join(outputDir, strings.NewReplacer(".", "-", "/", "-").Replace(group))
+		if err := os.MkdirAll(sectionDir, os.ModePerm); err != nil {
+			return fmt.Errorf("rendering site: creating section directory for %s: %w", group, err)
+		}
+		section := pkg.SiteSection{Group: group, Path: sectionDir, Pages: pages}
+		sections = append(sections, section)
+
+		// The index page lives in sectionDir, not at the site root, so its
+		// own links need to be relative to sectionDir even though
+		// section.Pages (used by NavFragment) stays root-relative.
+		indexPages := make([]string, len(pages))
+		for i, page := range pages {
+			rel, err := filepath.Rel(sectionDir, filepath.Join(outputDir, page))
+			if err != nil {
+				return fmt.Errorf("rendering site: computing index link for %s: %w", page, err)
+			}
+			indexPages[i] = rel
+		}
+
+		index, err := renderer.IndexPage(pkg.SiteSection{Group: group, Path: sectionDir, Pages: indexPages})
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(sectionDir, renderer.IndexFilename()), index, os.ModePerm); err != nil {
+			return fmt.Errorf("rendering site: writing section index for %s: %w", group, err)
+		}
+	}
+
+	navContent, navFilename, err := renderer.NavFragment(sections)
+	if err != nil {
+		return err
+	}
+	if navFilename != "" {
+		if err := os.WriteFile(filepath.Join(outputDir, navFilename), navContent, os.ModePerm); err != nil {
+			return fmt.Errorf("rendering site: writing navigation fragment: %w", err)
+		}
+	}
+
+	return nil
+}
+
 func main() {
 	// Run the cli
 	if err := RootCmd().Execute(); err != nil {
@@ -0,0 +1,113 @@
+// Copyright 2021 IBM Corp.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func testCRD(group, kind string, shortNames []string, versions ...string) apiextensionsv1.CustomResourceDefinition {
+	crd := apiextensionsv1.CustomResourceDefinition{
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Kind:       kind,
+				ShortNames: shortNames,
+			},
+		},
+	}
+	for i, v := range versions {
+		crd.Spec.Versions = append(crd.Spec.Versions, apiextensionsv1.CustomResourceDefinitionVersion{
+			Name:    v,
+			Storage: i == len(versions)-1,
+		})
+	}
+	return crd
+}
+
+func TestEvalOutputTemplateDefaultGroupsByGroup(t *testing.T) {
+	tmpl, err := newOutputTemplate(defaultOutputTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gateway := testCRD("networking.istio.io", "Gateway", nil, "v1beta1")
+	vs := testCRD("networking.istio.io", "VirtualService", nil, "v1beta1")
+
+	for _, crd := range []apiextensionsv1.CustomResourceDefinition{gateway, vs} {
+		rel, err := evalOutputTemplate(tmpl, crd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "networking-istio-io.md"; rel != want {
+			t.Errorf("evalOutputTemplate(%s) = %q, want %q", crd.Spec.Names.Kind, rel, want)
+		}
+	}
+}
+
+func TestEvalOutputTemplatePerKind(t *testing.T) {
+	tmpl, err := newOutputTemplate(`{{.Group | replace "." "-"}}/{{.Kind | lower}}.md`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crd := testCRD("networking.istio.io", "VirtualService", nil, "v1beta1")
+	rel, err := evalOutputTemplate(tmpl, crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "networking-istio-io/virtualservice.md"; rel != want {
+		t.Errorf("evalOutputTemplate() = %q, want %q", rel, want)
+	}
+}
+
+func TestEvalOutputTemplatePerVersion(t *testing.T) {
+	tmpl, err := newOutputTemplate(`{{.Group}}/{{.Kind | lower}}/{{.Version}}.md`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crd := testCRD("networking.istio.io", "Gateway", nil, "v1alpha1", "v1beta1")
+	rel, err := evalOutputTemplate(tmpl, crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "networking.istio.io/gateway/v1beta1.md"; rel != want {
+		t.Errorf("evalOutputTemplate() = %q, want %q (should use the storage version)", rel, want)
+	}
+}
+
+func TestEvalOutputTemplatePerGroup(t *testing.T) {
+	tmpl, err := newOutputTemplate(`{{.Group}}.md`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crd := testCRD("networking.istio.io", "Gateway", nil, "v1beta1")
+	rel, err := evalOutputTemplate(tmpl, crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "networking.istio.io.md"; rel != want {
+		t.Errorf("evalOutputTemplate() = %q, want %q", rel, want)
+	}
+}
+
+func TestEvalOutputTemplateShortNames(t *testing.T) {
+	tmpl, err := newOutputTemplate(`{{index .ShortNames 0}}.md`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crd := testCRD("networking.istio.io", "Gateway", []string{"gw"}, "v1beta1")
+	rel, err := evalOutputTemplate(tmpl, crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "gw.md"; rel != want {
+		t.Errorf("evalOutputTemplate() = %q, want %q", rel, want)
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2021 IBM Corp.
+// SPDX-License-Identifier: Apache-2.0
+
+package builder
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// Supported values for --emit.
+const (
+	EmitMarkdown   = "markdown"
+	EmitJSONSchema = "jsonschema"
+	EmitAsciiDoc   = "asciidoc"
+	EmitHTML       = "html"
+)
+
+// CRDEmitter produces one artifact per CRD, independent of how CRDs are
+// grouped into output files (e.g. a standalone JSON Schema per version).
+type CRDEmitter interface {
+	EmitCRD(crd apiextensionsv1.CustomResourceDefinition, markdownPath string) error
+}
+
+// DocEmitter converts an already-rendered markdown output file into
+// another document format.
+type DocEmitter interface {
+	EmitDoc(markdownPath string) error
+}
+
+// goldmarkConverter parses GitHub-flavored markdown, including tables,
+// which crdoc's markdown.tmpl output relies on for field listings. Both
+// the HTML and AsciiDoc emitters share it.
+var goldmarkConverter = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// NewEmitters parses a comma-separated --emit value into the emitters
+// crdoc should run in addition to its default markdown output, and
+// reports whether "markdown" itself was requested (ModelBuilder.Output
+// always writes it; callers remove the file afterwards when it wasn't).
+// templates is the embed.FS carrying crdoc's builtin templates, including
+// the asciidoc.tmpl the AsciiDoc emitter wraps its output in.
+func NewEmitters(formats []string, templates embed.FS) (crdEmitters []CRDEmitter, docEmitters []DocEmitter, keepMarkdown bool, err error) {
+	if len(formats) == 0 {
+		return nil, nil, true, nil
+	}
+
+	for _, format := range formats {
+		format = strings.TrimSpace(format)
+		switch format {
+		case EmitMarkdown:
+			keepMarkdown = true
+		case EmitJSONSchema:
+			crdEmitters = append(crdEmitters, jsonSchemaEmitter{})
+		case EmitAsciiDoc:
+			emitter, err := newAsciiDocEmitter(templates)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			docEmitters = append(docEmitters, emitter)
+		case EmitHTML:
+			docEmitters = append(docEmitters, htmlEmitter{})
+		default:
+			return nil, nil, false, fmt.Errorf("unknown --emit value %q (want one of %q, %q, %q, %q)", format, EmitMarkdown, EmitJSONSchema, EmitAsciiDoc, EmitHTML)
+		}
+	}
+
+	return crdEmitters, docEmitters, keepMarkdown, nil
+}
+
+// jsonSchemaEmitter writes the flattened openAPIV3Schema of each CRD
+// version as a standalone JSON Schema draft-07 document.
+type jsonSchemaEmitter struct{}
+
+// EmitCRD implements CRDEmitter.
+func (jsonSchemaEmitter) EmitCRD(crd apiextensionsv1.CustomResourceDefinition, markdownPath string) error {
+	base := strings.TrimSuffix(markdownPath, filepath.Ext(markdownPath))
+
+	for _, version := range crd.Spec.Versions {
+		if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+
+		schema := jsonSchemaFromCRDSchema(version.Schema.OpenAPIV3Schema)
+		schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+		schema["title"] = fmt.Sprintf("%s.%s.%s", crd.Spec.Group, version.Name, crd.Spec.Names.Kind)
+
+		encoded, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding JSON Schema for %s/%s %s: %w", crd.Spec.Group, version.Name, crd.Spec.Names.Kind, err)
+		}
+
+		path := fmt.Sprintf("%s.%s.%s.schema.json", base, strings.ToLower(crd.Spec.Names.Kind), version.Name)
+		if err := os.WriteFile(path, encoded, os.ModePerm); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// jsonSchemaFromCRDSchema flattens a CRD's openAPIV3Schema into a plain
+// JSON Schema draft-07 document, resolving the two Kubernetes extensions
+// that have no draft-07 equivalent: x-kubernetes-preserve-unknown-fields
+// becomes "additionalProperties": true, and x-kubernetes-int-or-string
+// becomes a oneOf [integer, string].
+func jsonSchemaFromCRDSchema(schema *apiextensionsv1.JSONSchemaProps) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+
+	if schema.XIntOrString {
+		return map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "integer"},
+				map[string]interface{}{"type": "string"},
+			},
+		}
+	}
+
+	out := map[string]interface{}{}
+	if schema.Type != "" {
+		out["type"] = schema.Type
+	}
+	if schema.Format != "" {
+		out["format"] = schema.Format
+	}
+	if schema.Description != "" {
+		out["description"] = schema.Description
+	}
+	if len(schema.Required) > 0 {
+		out["required"] = schema.Required
+	}
+
+	if len(schema.Properties) > 0 {
+		properties := make(map[string]interface{}, len(schema.Properties))
+		for name, prop := range schema.Properties {
+			prop := prop
+			properties[name] = jsonSchemaFromCRDSchema(&prop)
+		}
+		out["properties"] = properties
+	}
+
+	if schema.Items != nil && schema.Items.Schema != nil {
+		out["items"] = jsonSchemaFromCRDSchema(schema.Items.Schema)
+	}
+
+	if schema.AdditionalProperties != nil {
+		if schema.AdditionalProperties.Schema != nil {
+			out["additionalProperties"] = jsonSchemaFromCRDSchema(schema.AdditionalProperties.Schema)
+		} else {
+			out["additionalProperties"] = schema.AdditionalProperties.Allows
+		}
+	} else if schema.XPreserveUnknownFields != nil && *schema.XPreserveUnknownFields {
+		out["additionalProperties"] = true
+	}
+
+	return out
+}
+
+// asciiDocEmitter renders a crdoc markdown output file as AsciiDoc, via the
+// builtin templates/asciidoc.tmpl.
+type asciiDocEmitter struct {
+	tmpl *template.Template
+}
+
+func newAsciiDocEmitter(templates embed.FS) (asciiDocEmitter, error) {
+	tmpl, err := template.ParseFS(templates, "templates/asciidoc.tmpl")
+	if err != nil {
+		return asciiDocEmitter{}, fmt.Errorf("loading builtin asciidoc template: %w", err)
+	}
+	return asciiDocEmitter{tmpl: tmpl}, nil
+}
+
+// EmitDoc implements DocEmitter.
+func (e asciiDocEmitter) EmitDoc(markdownPath string) error {
+	content, err := os.ReadFile(markdownPath)
+	if err != nil {
+		return err
+	}
+
+	body, err := markdownToAsciiDoc(content)
+	if err != nil {
+		return fmt.Errorf("converting %s to AsciiDoc: %w", markdownPath, err)
+	}
+
+	title := strings.TrimSuffix(filepath.Base(markdownPath), filepath.Ext(markdownPath))
+
+	var adoc bytes.Buffer
+	if err := e.tmpl.Execute(&adoc, struct {
+		Title string
+		Body  string
+	}{Title: title, Body: body}); err != nil {
+		return fmt.Errorf("rendering asciidoc template for %s: %w", markdownPath, err)
+	}
+
+	path := strings.TrimSuffix(markdownPath, filepath.Ext(markdownPath)) + ".adoc"
+	return os.WriteFile(path, adoc.Bytes(), os.ModePerm)
+}
+
+// markdownToAsciiDoc walks goldmark's parse tree for source and renders it
+// as AsciiDoc, including GFM tables, which make up most of crdoc's
+// markdown.tmpl output.
+func markdownToAsciiDoc(source []byte) (string, error) {
+	doc := goldmarkConverter.Parser().Parse(text.NewReader(source))
+
+	var buf bytes.Buffer
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch node := n.(type) {
+		case *ast.Heading:
+			if entering {
+				buf.WriteString(strings.Repeat("=", node.Level) + " ")
+			} else {
+				buf.WriteString("\n\n")
+			}
+		case *ast.Paragraph:
+			if !entering {
+				buf.WriteString("\n\n")
+			}
+		case *ast.Text:
+			if entering {
+				buf.Write(node.Segment.Value(source))
+				if node.SoftLineBreak() || node.HardLineBreak() {
+					buf.WriteString("\n")
+				}
+			}
+		case *ast.String:
+			if entering {
+				buf.Write(node.Value)
+			}
+		case *ast.CodeSpan:
+			buf.WriteString("`")
+		case *ast.Emphasis:
+			marker := "_"
+			if node.Level >= 2 {
+				marker = "*"
+			}
+			buf.WriteString(marker)
+		case *ast.Link:
+			if entering {
+				buf.WriteString("link:" + string(node.Destination) + "[")
+			} else {
+				buf.WriteString("]")
+			}
+		case *ast.ListItem:
+			if entering {
+				buf.WriteString("* ")
+			} else {
+				buf.WriteString("\n")
+			}
+		case *ast.FencedCodeBlock:
+			if entering {
+				buf.WriteString("----\n")
+				for i := 0; i < node.Lines().Len(); i++ {
+					line := node.Lines().At(i)
+					buf.Write(line.Value(source))
+				}
+				buf.WriteString("----\n\n")
+				return ast.WalkSkipChildren, nil
+			}
+		case *extast.Table:
+			if entering {
+				cols := make([]string, len(node.Alignments))
+				for i := range cols {
+					cols[i] = "1"
+				}
+				buf.WriteString(fmt.Sprintf("[cols=\"%s\",options=\"header\"]\n|===\n", strings.Join(cols, ",")))
+			} else {
+				buf.WriteString("|===\n\n")
+			}
+		case *extast.TableHeader:
+			if !entering {
+				buf.WriteString("\n")
+			}
+		case *extast.TableRow:
+			if !entering {
+				buf.WriteString("\n")
+			}
+		case *extast.TableCell:
+			if entering {
+				buf.WriteString("| ")
+			} else {
+				buf.WriteString(" ")
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(buf.String(), "\n") + "\n", nil
+}
+
+// htmlEmitter renders a crdoc markdown output file to self-contained HTML.
+type htmlEmitter struct{}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { max-width: 60rem; margin: 2rem auto; padding: 0 1rem; font-family: sans-serif; line-height: 1.5; }
+code, pre { background: #f4f4f4; padding: 0.2rem 0.4rem; border-radius: 3px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ddd; padding: 0.4rem 0.8rem; }
+</style>
+</head>
+<body>
+%s
+</body>
+</html>
+`
+
+// EmitDoc implements DocEmitter.
+func (htmlEmitter) EmitDoc(markdownPath string) error {
+	content, err := os.ReadFile(markdownPath)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if err := goldmarkConverter.Convert(content, &body); err != nil {
+		return fmt.Errorf("rendering %s to HTML: %w", markdownPath, err)
+	}
+
+	title := strings.TrimSuffix(filepath.Base(markdownPath), filepath.Ext(markdownPath))
+	html := fmt.Sprintf(htmlTemplate, title, body.String())
+
+	path := strings.TrimSuffix(markdownPath, filepath.Ext(markdownPath)) + ".html"
+	return os.WriteFile(path, []byte(html), os.ModePerm)
+}
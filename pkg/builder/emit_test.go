@@ -0,0 +1,149 @@
+// Copyright 2021 IBM Corp.
+// SPDX-License-Identifier: Apache-2.0
+
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// readGolden returns a testdata/golden file's content, normalized the same
+// way got is before comparison: trailing whitespace on each line and
+// trailing blank lines don't affect the result, since exact column padding
+// isn't part of what these tests are asserting on.
+func readGolden(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", "golden", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return normalizeForCompare(string(content))
+}
+
+func normalizeForCompare(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// copyFixtureMarkdown copies testdata/sample.md into dir, so each test
+// writes its emitted output next to a throwaway copy rather than into the
+// repo's testdata directory.
+func copyFixtureMarkdown(t *testing.T, dir string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", "sample.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "sample.md")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sampleWidgetCRD() apiextensionsv1.CustomResourceDefinition {
+	return apiextensionsv1.CustomResourceDefinition{
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "example.com",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: "Widget"},
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{
+					Name: "v1",
+					Schema: &apiextensionsv1.CustomResourceValidation{
+						OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+							Type:     "object",
+							Required: []string{"size"},
+							Properties: map[string]apiextensionsv1.JSONSchemaProps{
+								"size":  {Type: "integer"},
+								"color": {Type: "string"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestJSONSchemaEmitterGolden(t *testing.T) {
+	dir := t.TempDir()
+	markdownPath := copyFixtureMarkdown(t, dir)
+
+	if err := (jsonSchemaEmitter{}).EmitCRD(sampleWidgetCRD(), markdownPath); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "sample.widget.v1.schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if normalizeForCompare(string(got)) != readGolden(t, "sample.v1.schema.json") {
+		t.Errorf("EmitCRD JSON Schema output:\n%s\n\nwant (testdata/golden/sample.v1.schema.json):\n%s", got, readGolden(t, "sample.v1.schema.json"))
+	}
+}
+
+func TestAsciiDocEmitterGolden(t *testing.T) {
+	dir := t.TempDir()
+	markdownPath := copyFixtureMarkdown(t, dir)
+
+	// Mirrors templates/asciidoc.tmpl; newAsciiDocEmitter loads that file
+	// through an embed.FS rooted at the repo root, which pkg/builder's own
+	// tests can't embed (go:embed can't reach outside its package
+	// directory), so the template is inlined here instead.
+	tmpl, err := template.New("asciidoc").Parse("= {{ .Title }}\n\n{{ .Body }}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	emitter := asciiDocEmitter{tmpl: tmpl}
+	if err := emitter.EmitDoc(markdownPath); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "sample.adoc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if normalizeForCompare(string(got)) != readGolden(t, "sample.adoc") {
+		t.Errorf("EmitDoc AsciiDoc output:\n%s\n\nwant (testdata/golden/sample.adoc):\n%s", got, readGolden(t, "sample.adoc"))
+	}
+}
+
+// TestHTMLEmitterRendersTables checks that the markdown table in
+// testdata/sample.md comes out as an actual HTML <table>, which is what
+// review comment 8 was about (goldmark.Convert's default instance has no
+// table extension enabled). It asserts on structure rather than a
+// byte-exact golden file, since goldmark's own HTML formatting (escaping,
+// line breaks) isn't independently reproducible here - the emitter change
+// under test is "which extensions goldmark runs with", not its renderer
+// output.
+func TestHTMLEmitterRendersTables(t *testing.T) {
+	dir := t.TempDir()
+	markdownPath := copyFixtureMarkdown(t, dir)
+
+	if err := (htmlEmitter{}).EmitDoc(markdownPath); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "sample.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(got)
+
+	for _, want := range []string{"<table>", "<th>Name</th>", "<td>size</td>", "</table>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("EmitDoc HTML output missing %q; got:\n%s", want, html)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright 2021 IBM Corp.
+// SPDX-License-Identifier: Apache-2.0
+
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// OpenAPISource converts one or more OpenAPI v2 ("swagger.json") or v3
+// documents into the same CustomResourceDefinition model LoadCRDs produces
+// from CRD YAML, so a single crdoc run can render CRDs and built-in
+// Kubernetes types through the same ModelBuilder and templates.
+type OpenAPISource struct {
+	// Paths are the OpenAPI documents to load.
+	Paths []string
+	// OnlyGroups, when non-empty, keeps only schemas whose
+	// x-kubernetes-group-version-kind group is in this set.
+	OnlyGroups []string
+}
+
+// Load implements CRDSource.
+func (s OpenAPISource) Load() ([]apiextensionsv1.CustomResourceDefinition, error) {
+	docs := make(map[string]*openAPIDocument, len(s.Paths))
+	for _, path := range s.Paths {
+		doc, err := loadOpenAPIDocument(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading OpenAPI document %s: %w", path, err)
+		}
+		docs[path] = doc
+	}
+
+	onlyGroups := make(map[string]bool, len(s.OnlyGroups))
+	for _, g := range s.OnlyGroups {
+		onlyGroups[g] = true
+	}
+
+	// Paths (and each document's own definitions) are iterated in a fixed
+	// order - s.Paths as given, definition names sorted - rather than
+	// ranging the docs/definitions maps directly, so the generated CRDs
+	// (and the output file sections they land in) come out in the same
+	// order on every run.
+	var crds []apiextensionsv1.CustomResourceDefinition
+	for _, path := range s.Paths {
+		doc := docs[path]
+		defs := doc.definitions()
+		names := make([]string, 0, len(defs))
+		for name := range defs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			def := defs[name]
+			gvks := def.XKubernetesGroupVersionKind
+			if len(gvks) == 0 {
+				continue // not a Kubernetes API type
+			}
+
+			resolved, resolvedPath, err := resolveOpenAPISchema(def, path, docs, nil)
+			if err != nil {
+				return nil, fmt.Errorf("resolving %s: %w", name, err)
+			}
+			schema, err := resolved.toJSONSchemaProps(resolvedPath, docs, nil)
+			if err != nil {
+				return nil, fmt.Errorf("converting %s: %w", name, err)
+			}
+
+			for _, gvk := range gvks {
+				if len(onlyGroups) > 0 && !onlyGroups[gvk.Group] {
+					continue
+				}
+				crds = append(crds, syntheticCRD(gvk, schema))
+			}
+		}
+	}
+
+	return crds, nil
+}
+
+// gvkExtension is the x-kubernetes-group-version-kind vendor extension.
+type gvkExtension struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// openAPISchema is a minimal OpenAPI v2/v3 schema object, carrying just
+// the fields crdoc needs to flatten a definition into
+// apiextensionsv1.JSONSchemaProps.
+type openAPISchema struct {
+	Type        string                   `json:"type,omitempty"`
+	Format      string                   `json:"format,omitempty"`
+	Description string                   `json:"description,omitempty"`
+	Ref         string                   `json:"$ref,omitempty"`
+	Properties  map[string]openAPISchema `json:"properties,omitempty"`
+	Items       *openAPISchema           `json:"items,omitempty"`
+	Required    []string                 `json:"required,omitempty"`
+
+	AdditionalProperties *openAPISchemaOrBool `json:"additionalProperties,omitempty"`
+
+	XKubernetesGroupVersionKind      []gvkExtension `json:"x-kubernetes-group-version-kind,omitempty"`
+	XKubernetesListType              *string        `json:"x-kubernetes-list-type,omitempty"`
+	XKubernetesListMapKeys           []string       `json:"x-kubernetes-list-map-keys,omitempty"`
+	XKubernetesPatchStrategy         string         `json:"x-kubernetes-patch-strategy,omitempty"`
+	XKubernetesPatchMergeKey         string         `json:"x-kubernetes-patch-merge-key,omitempty"`
+	XKubernetesPreserveUnknownFields *bool          `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+	XKubernetesIntOrString           bool           `json:"x-kubernetes-int-or-string,omitempty"`
+}
+
+// openAPISchemaOrBool mirrors OpenAPI's additionalProperties field, which is
+// either a plain boolean or a nested schema object that implicitly allows
+// additional properties matching it.
+type openAPISchemaOrBool struct {
+	Allows bool
+	Schema *openAPISchema
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *openAPISchemaOrBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true":
+		s.Allows = true
+		return nil
+	case "false":
+		s.Allows = false
+		return nil
+	default:
+		s.Allows = true
+		return json.Unmarshal(data, &s.Schema)
+	}
+}
+
+// openAPIDocument is the subset of a swagger.json (v2) or openapi.json
+// (v3) document crdoc reads definitions from.
+type openAPIDocument struct {
+	Definitions map[string]openAPISchema `json:"definitions,omitempty"` // OpenAPI v2
+	Components  struct {
+		Schemas map[string]openAPISchema `json:"schemas,omitempty"` // OpenAPI v3
+	} `json:"components,omitempty"`
+}
+
+// definitions returns the document's definitions regardless of whether it
+// is an OpenAPI v2 or v3 document.
+func (d *openAPIDocument) definitions() map[string]openAPISchema {
+	if len(d.Definitions) > 0 {
+		return d.Definitions
+	}
+	return d.Components.Schemas
+}
+
+func loadOpenAPIDocument(path string) (*openAPIDocument, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc openAPIDocument
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &doc, nil
+}
+
+// resolveOpenAPISchema follows $ref, including across files, returning the
+// first non-$ref schema found together with the path of the document it
+// was found in - callers need that path to resolve any $refs nested inside
+// the returned schema, since those are relative to where the schema lives,
+// not to fromPath. visited guards against reference cycles.
+func resolveOpenAPISchema(schema openAPISchema, fromPath string, docs map[string]*openAPIDocument, visited map[string]bool) (resolved openAPISchema, resolvedPath string, err error) {
+	if schema.Ref == "" {
+		return schema, fromPath, nil
+	}
+
+	if visited == nil {
+		visited = make(map[string]bool)
+	}
+	key := fromPath + schema.Ref
+	if visited[key] {
+		return openAPISchema{}, "", fmt.Errorf("cycle detected resolving $ref %s", schema.Ref)
+	}
+	visited[key] = true
+
+	docPath, name, err := splitRef(schema.Ref, fromPath)
+	if err != nil {
+		return openAPISchema{}, "", err
+	}
+
+	doc, ok := docs[docPath]
+	if !ok {
+		loaded, err := loadOpenAPIDocument(docPath)
+		if err != nil {
+			return openAPISchema{}, "", fmt.Errorf("resolving $ref %s: %w", schema.Ref, err)
+		}
+		docs[docPath] = loaded
+		doc = loaded
+	}
+
+	target, ok := doc.definitions()[name]
+	if !ok {
+		return openAPISchema{}, "", fmt.Errorf("$ref %s: definition %q not found in %s", schema.Ref, name, docPath)
+	}
+
+	return resolveOpenAPISchema(target, docPath, docs, visited)
+}
+
+// splitRef splits a $ref such as "other.json#/definitions/Foo" (or the
+// in-document "#/components/schemas/Foo") into the document path it
+// targets and the bare definition name.
+func splitRef(ref, fromPath string) (docPath, name string, err error) {
+	parts := strings.SplitN(ref, "#", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("unsupported $ref %q: expected a fragment", ref)
+	}
+
+	docPath = fromPath
+	if parts[0] != "" {
+		docPath = parts[0]
+		if !filepath.IsAbs(docPath) {
+			docPath = filepath.Join(filepath.Dir(fromPath), docPath)
+		}
+	}
+
+	fragment := strings.TrimPrefix(parts[1], "/")
+	segments := strings.Split(fragment, "/")
+	name = segments[len(segments)-1]
+	return docPath, name, nil
+}
+
+// toJSONSchemaProps flattens an OpenAPI schema, resolving any nested
+// $refs, into the apiextensionsv1.JSONSchemaProps the rest of crdoc's
+// pipeline already understands. inProgress tracks the $refs currently
+// being expanded on the path from the root call to this one (keyed the
+// same way resolveOpenAPISchema keys its own visited set); a $ref already
+// in inProgress means a schema reaches itself through its own properties,
+// items or additionalProperties (real Kubernetes types do this, e.g.
+// JSONSchemaProps referencing itself via "not"), so it is stubbed out
+// instead of expanded again to avoid recursing forever. Passing nil is
+// fine for any call site that isn't itself already inside a resolution.
+func (s openAPISchema) toJSONSchemaProps(fromPath string, docs map[string]*openAPIDocument, inProgress map[string]bool) (apiextensionsv1.JSONSchemaProps, error) {
+	if inProgress == nil {
+		inProgress = make(map[string]bool)
+	}
+
+	if s.Ref != "" {
+		key := fromPath + s.Ref
+		if inProgress[key] {
+			return apiextensionsv1.JSONSchemaProps{
+				Type:        "object",
+				Description: fmt.Sprintf("(recursive reference to %s, not expanded further)", s.Ref),
+			}, nil
+		}
+
+		resolved, resolvedPath, err := resolveOpenAPISchema(s, fromPath, docs, nil)
+		if err != nil {
+			return apiextensionsv1.JSONSchemaProps{}, err
+		}
+
+		inProgress[key] = true
+		defer delete(inProgress, key)
+		return resolved.toJSONSchemaProps(resolvedPath, docs, inProgress)
+	}
+
+	out := apiextensionsv1.JSONSchemaProps{
+		Type:                   s.Type,
+		Format:                 s.Format,
+		Description:            s.Description,
+		Required:               s.Required,
+		XIntOrString:           s.XKubernetesIntOrString,
+		XPreserveUnknownFields: s.XKubernetesPreserveUnknownFields,
+		XListType:              s.XKubernetesListType,
+	}
+	if len(s.XKubernetesListMapKeys) > 0 {
+		out.XListMapKeys = s.XKubernetesListMapKeys
+	}
+
+	if len(s.Properties) > 0 {
+		out.Properties = make(map[string]apiextensionsv1.JSONSchemaProps, len(s.Properties))
+		for name, prop := range s.Properties {
+			converted, err := prop.toJSONSchemaProps(fromPath, docs, inProgress)
+			if err != nil {
+				return apiextensionsv1.JSONSchemaProps{}, fmt.Errorf("property %s: %w", name, err)
+			}
+			out.Properties[name] = converted
+		}
+	}
+
+	if s.Items != nil {
+		converted, err := s.Items.toJSONSchemaProps(fromPath, docs, inProgress)
+		if err != nil {
+			return apiextensionsv1.JSONSchemaProps{}, fmt.Errorf("items: %w", err)
+		}
+		out.Items = &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &converted}
+	}
+
+	if s.AdditionalProperties != nil {
+		out.AdditionalProperties = &apiextensionsv1.JSONSchemaPropsOrBool{Allows: s.AdditionalProperties.Allows}
+		if s.AdditionalProperties.Schema != nil {
+			converted, err := s.AdditionalProperties.Schema.toJSONSchemaProps(fromPath, docs, inProgress)
+			if err != nil {
+				return apiextensionsv1.JSONSchemaProps{}, fmt.Errorf("additionalProperties: %w", err)
+			}
+			out.AdditionalProperties.Schema = &converted
+		}
+	}
+
+	return out, nil
+}
+
+// syntheticCRD wraps a flattened OpenAPI schema in a CustomResourceDefinition
+// shell so it can flow through the existing builder pipeline unchanged.
+func syntheticCRD(gvk gvkExtension, schema apiextensionsv1.JSONSchemaProps) apiextensionsv1.CustomResourceDefinition {
+	return apiextensionsv1.CustomResourceDefinition{
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: gvk.Group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Kind:   gvk.Kind,
+				Plural: strings.ToLower(gvk.Kind) + "s",
+			},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{
+					Name:    gvk.Version,
+					Served:  true,
+					Storage: true,
+					Schema: &apiextensionsv1.CustomResourceValidation{
+						OpenAPIV3Schema: &schema,
+					},
+				},
+			},
+		},
+	}
+}
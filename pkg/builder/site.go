@@ -0,0 +1,186 @@
+// Copyright 2021 IBM Corp.
+// SPDX-License-Identifier: Apache-2.0
+
+package builder
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Supported values for --output-format.
+const (
+	FormatPlain      = "plain"
+	FormatHugo       = "hugo"
+	FormatDocusaurus = "docusaurus"
+	FormatMkDocs     = "mkdocs"
+)
+
+// FrontmatterData is the data made available to a SiteRenderer's
+// frontmatter template.
+type FrontmatterData struct {
+	Title       string
+	Weight      int
+	Description string
+	Aliases     []string
+}
+
+// SiteSection describes one generated group directory, used to build the
+// site-wide section index (_index.md / index.mdx) and navigation fragment
+// (sidebar.js / mkdocs.yml).
+type SiteSection struct {
+	// Group is the section's label: a CRD group, or the name of the TOC
+	// section that claimed its CRDs.
+	Group string
+	// Path is the section's directory: the site root joined with a slug
+	// of Group.
+	Path string
+	// Pages are the output paths of the individual CRD pages under this
+	// section: relative to the site root for NavFragment, relative to Path
+	// for IndexPage.
+	Pages []string
+}
+
+// SiteRenderer adapts crdoc's plain markdown output to a static site
+// generator's conventions: frontmatter, section indexes and navigation
+// fragments. A nil SiteRenderer (FormatPlain) leaves the default markdown
+// output untouched.
+type SiteRenderer interface {
+	// Frontmatter renders the frontmatter block to prepend to a generated
+	// page, in the site's native format (YAML, TOML or MDX import + export).
+	Frontmatter(data FrontmatterData) (string, error)
+	// IndexFilename is the section index filename the site generator
+	// expects in each group directory (e.g. "_index.md", "index.mdx").
+	IndexFilename() string
+	// IndexPage renders a group directory's listing page, including its
+	// own frontmatter.
+	IndexPage(section SiteSection) ([]byte, error)
+	// NavFragment renders the navigation fragment (sidebar.js, mkdocs.yml
+	// snippet, ...) describing the generated sections.
+	NavFragment(sections []SiteSection) ([]byte, string, error)
+	// RewriteLink rewrites the target of an intra-doc markdown link (as
+	// crdoc's templates generate it, a path to another generated page
+	// relative to the linking page) into the form the site format expects
+	// once the page is served through it.
+	RewriteLink(target string) string
+}
+
+// NewSiteRenderer returns the SiteRenderer for the given --output-format
+// value, or nil for FormatPlain. templates is the embed.FS carrying
+// crdoc's builtin templates, including templates/<format>/*.
+func NewSiteRenderer(format string, templates embed.FS) (SiteRenderer, error) {
+	switch format {
+	case "", FormatPlain:
+		return nil, nil
+	case FormatHugo:
+		// Hugo has no separate navigation fragment to emit; each
+		// section's _index.md is written with a fixed weight of 0.
+		return newTemplatedRenderer(FormatHugo, "_index.md", "", templates)
+	case FormatDocusaurus:
+		return newTemplatedRenderer(FormatDocusaurus, "index.mdx", "sidebar.js", templates)
+	case FormatMkDocs:
+		return newTemplatedRenderer(FormatMkDocs, "index.md", "mkdocs.yml", templates)
+	default:
+		return nil, fmt.Errorf("unknown --output-format %q (want one of %q, %q, %q, %q)", format, FormatPlain, FormatHugo, FormatDocusaurus, FormatMkDocs)
+	}
+}
+
+// templatedRenderer implements SiteRenderer on top of the builtin
+// templates/<format>/frontmatter.tmpl and templates/<format>/nav.tmpl
+// templates, shared by the hugo, docusaurus and mkdocs formats since they
+// only differ in filenames and template content.
+type templatedRenderer struct {
+	format        string
+	indexFilename string
+	navFilename   string
+	frontmatter   *template.Template
+	index         *template.Template
+	nav           *template.Template
+}
+
+func newTemplatedRenderer(format, indexFilename, navFilename string, templates embed.FS) (*templatedRenderer, error) {
+	frontmatter, err := template.ParseFS(templates, "templates/"+format+"/frontmatter.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("loading builtin %s frontmatter template: %w", format, err)
+	}
+
+	index, err := template.ParseFS(templates, "templates/"+format+"/index.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("loading builtin %s index template: %w", format, err)
+	}
+
+	var nav *template.Template
+	if navFilename != "" {
+		nav, err = template.ParseFS(templates, "templates/"+format+"/nav.tmpl")
+		if err != nil {
+			return nil, fmt.Errorf("loading builtin %s nav template: %w", format, err)
+		}
+	}
+
+	return &templatedRenderer{
+		format:        format,
+		indexFilename: indexFilename,
+		navFilename:   navFilename,
+		frontmatter:   frontmatter,
+		index:         index,
+		nav:           nav,
+	}, nil
+}
+
+// Frontmatter implements SiteRenderer.
+func (r *templatedRenderer) Frontmatter(data FrontmatterData) (string, error) {
+	var buf bytes.Buffer
+	if err := r.frontmatter.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering %s frontmatter: %w", r.format, err)
+	}
+	return buf.String(), nil
+}
+
+// IndexFilename implements SiteRenderer.
+func (r *templatedRenderer) IndexFilename() string {
+	return r.indexFilename
+}
+
+// IndexPage implements SiteRenderer.
+func (r *templatedRenderer) IndexPage(section SiteSection) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.index.Execute(&buf, section); err != nil {
+		return nil, fmt.Errorf("rendering %s section index for %s: %w", r.format, section.Group, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// NavFragment implements SiteRenderer. It returns an empty filename when
+// the format has no separate navigation fragment (e.g. Hugo).
+func (r *templatedRenderer) NavFragment(sections []SiteSection) ([]byte, string, error) {
+	if r.nav == nil {
+		return nil, "", nil
+	}
+	var buf bytes.Buffer
+	if err := r.nav.Execute(&buf, sections); err != nil {
+		return nil, "", fmt.Errorf("rendering %s navigation fragment: %w", r.format, err)
+	}
+	return buf.Bytes(), r.navFilename, nil
+}
+
+// RewriteLink implements SiteRenderer. Hugo and Docusaurus both serve
+// pages at pretty URLs with the .md source extension stripped, so a link
+// generated against crdoc's markdown output has to drop that extension to
+// still resolve once the site is built; MkDocs instead resolves relative
+// links that point at another .md source file itself, rewriting them to
+// the built .html path, so crdoc's own links need no change there.
+func (r *templatedRenderer) RewriteLink(target string) string {
+	if strings.Contains(target, "://") {
+		return target
+	}
+	switch r.format {
+	case FormatMkDocs:
+		return target
+	default:
+		return strings.TrimSuffix(target, filepath.Ext(target))
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 IBM Corp.
+// SPDX-License-Identifier: Apache-2.0
+
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	typedapiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// CRDSource produces the set of CustomResourceDefinitions that crdoc should
+// document. It is the single extension point for where CRDs come from,
+// letting new sources (a registry, a git repo, ...) plug in without
+// changing anything downstream of LoadCRDs.
+type CRDSource interface {
+	Load() ([]apiextensionsv1.CustomResourceDefinition, error)
+}
+
+// DirSource loads every CRD manifest found under a directory.
+type DirSource struct {
+	Path string
+}
+
+// Load implements CRDSource.
+func (s DirSource) Load() ([]apiextensionsv1.CustomResourceDefinition, error) {
+	return LoadCRDs(s.Path)
+}
+
+// FileSource loads the CRDs contained in a single manifest file.
+type FileSource struct {
+	Path string
+}
+
+// Load implements CRDSource.
+func (s FileSource) Load() ([]apiextensionsv1.CustomResourceDefinition, error) {
+	return LoadCRDs(s.Path)
+}
+
+// URLSource downloads a single manifest file over HTTP(S) and parses the
+// CRDs it contains.
+type URLSource struct {
+	URL string
+}
+
+// Load implements CRDSource.
+func (s URLSource) Load() ([]apiextensionsv1.CustomResourceDefinition, error) {
+	resp, err := http.Get(s.URL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", s.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected status %s", s.URL, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp("", "crdoc-url-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", s.URL, err)
+	}
+
+	return LoadCRDs(tmp.Name())
+}
+
+// ClusterSource lists CustomResourceDefinitions directly from a Kubernetes
+// API server using the apiextensions-apiserver clientset.
+type ClusterSource struct {
+	// Kubeconfig is the path to a kubeconfig file. Empty uses the default
+	// loading rules (KUBECONFIG env var, then ~/.kube/config).
+	Kubeconfig string
+	// Context overrides the current-context of the kubeconfig.
+	Context string
+	// Namespace is accepted for parity with other crdoc flags but is
+	// unused: CustomResourceDefinitions are cluster-scoped.
+	Namespace string
+	// LabelSelector filters CRDs by label, e.g. "app.kubernetes.io/part-of=istio".
+	LabelSelector string
+	// FieldSelector filters CRDs by field.
+	FieldSelector string
+	// GroupPrefix, when set, only keeps CRDs whose spec.group starts with it.
+	GroupPrefix string
+}
+
+// Load implements CRDSource.
+func (s ClusterSource) Load() ([]apiextensionsv1.CustomResourceDefinition, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if s.Kubeconfig != "" {
+		loadingRules.ExplicitPath = s.Kubeconfig
+	}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: s.Context},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig: %w", err)
+	}
+
+	clientset, err := apiextensionsclientset.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("building apiextensions client: %w", err)
+	}
+
+	return listCRDs(clientset.ApiextensionsV1().CustomResourceDefinitions(), s.LabelSelector, s.FieldSelector, s.GroupPrefix)
+}
+
+// listCRDs lists CustomResourceDefinitions through client and filters them
+// by label selector and group prefix. LabelSelector filtering is applied
+// client-side, in addition to being sent to the server, so ClusterSource
+// behaves the same way against a real API server and against a fake
+// clientset used in tests, which doesn't evaluate LabelSelector itself.
+func listCRDs(client typedapiextensionsv1.CustomResourceDefinitionInterface, labelSelector, fieldSelector, groupPrefix string) ([]apiextensionsv1.CustomResourceDefinition, error) {
+	var selector labels.Selector
+	if labelSelector != "" {
+		parsed, err := labels.Parse(labelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("parsing --selector %q: %w", labelSelector, err)
+		}
+		selector = parsed
+	}
+
+	list, err := client.List(context.Background(), metav1.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing CustomResourceDefinitions: %w", err)
+	}
+
+	crds := make([]apiextensionsv1.CustomResourceDefinition, 0, len(list.Items))
+	for _, crd := range list.Items {
+		if selector != nil && !selector.Matches(labels.Set(crd.Labels)) {
+			continue
+		}
+		if groupPrefix != "" && !strings.HasPrefix(crd.Spec.Group, groupPrefix) {
+			continue
+		}
+		crds = append(crds, crd)
+	}
+
+	return crds, nil
+}
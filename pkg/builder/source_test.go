@@ -0,0 +1,70 @@
+// Copyright 2021 IBM Corp.
+// SPDX-License-Identifier: Apache-2.0
+
+package builder
+
+import (
+	"sort"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestCRD(name, group string, labels map[string]string) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: group,
+		},
+	}
+}
+
+func TestListCRDsFiltersByGroupPrefix(t *testing.T) {
+	clientset := apiextensionsfake.NewSimpleClientset(
+		newTestCRD("gateways.networking.istio.io", "networking.istio.io", nil),
+		newTestCRD("virtualservices.networking.istio.io", "networking.istio.io", nil),
+		newTestCRD("widgets.example.com", "example.com", nil),
+	)
+
+	crds, err := listCRDs(clientset.ApiextensionsV1().CustomResourceDefinitions(), "", "", "networking.istio.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, crd := range crds {
+		names = append(names, crd.Name)
+	}
+	sort.Strings(names)
+
+	want := []string{"gateways.networking.istio.io", "virtualservices.networking.istio.io"}
+	if len(names) != len(want) || names[0] != want[0] || names[1] != want[1] {
+		t.Errorf("listCRDs() names = %v, want %v", names, want)
+	}
+}
+
+func TestListCRDsFiltersByLabelSelector(t *testing.T) {
+	clientset := apiextensionsfake.NewSimpleClientset(
+		newTestCRD("gateways.networking.istio.io", "networking.istio.io", map[string]string{"app.kubernetes.io/part-of": "istio"}),
+		newTestCRD("widgets.example.com", "example.com", map[string]string{"app.kubernetes.io/part-of": "other"}),
+	)
+
+	crds, err := listCRDs(clientset.ApiextensionsV1().CustomResourceDefinitions(), "app.kubernetes.io/part-of=istio", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(crds) != 1 || crds[0].Name != "gateways.networking.istio.io" {
+		t.Errorf("listCRDs() = %v, want only gateways.networking.istio.io", crds)
+	}
+}
+
+func TestListCRDsInvalidLabelSelector(t *testing.T) {
+	clientset := apiextensionsfake.NewSimpleClientset()
+
+	if _, err := listCRDs(clientset.ApiextensionsV1().CustomResourceDefinitions(), "===", "", ""); err == nil {
+		t.Error("listCRDs() with an invalid --selector: expected an error, got nil")
+	}
+}
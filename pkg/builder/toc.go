@@ -0,0 +1,137 @@
+// Copyright 2021 IBM Corp.
+// SPDX-License-Identifier: Apache-2.0
+
+package builder
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed toc.schema.json
+var tocSchemaFS embed.FS
+
+// TOCSection groups an arbitrary set of CRDs - by group, by a glob over
+// Kind, or by label - into a single output file, with its own template
+// and frontmatter metadata overrides.
+type TOCSection struct {
+	Name        string            `json:"name"`
+	Groups      []string          `json:"groups,omitempty"`
+	Kinds       []string          `json:"kinds,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Output      string            `json:"output"`
+	Template    string            `json:"template,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Weight      int               `json:"weight,omitempty"`
+	Description string            `json:"description,omitempty"`
+}
+
+// tocDocument is the extended table-of-contents schema: a named list of
+// sections that each own the CRDs they match, with a per-section output
+// file, template and frontmatter metadata.
+type tocDocument struct {
+	Sections []TOCSection `json:"sections"`
+}
+
+// Matches reports whether the section claims crd.
+func (s TOCSection) Matches(crd apiextensionsv1.CustomResourceDefinition) bool {
+	if len(s.Groups) > 0 {
+		matched := false
+		for _, g := range s.Groups {
+			if g == crd.Spec.Group {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(s.Kinds) > 0 {
+		matched := false
+		for _, glob := range s.Kinds {
+			if ok, _ := path.Match(glob, crd.Spec.Names.Kind); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(s.Labels) > 0 && !labels.SelectorFromSet(s.Labels).Matches(labels.Set(crd.Labels)) {
+		return false
+	}
+
+	return true
+}
+
+// LoadTOCSections parses the section-based table-of-contents schema out
+// of a toc.yaml file, validating it against the embedded JSON schema. It
+// returns no sections, without error, when path is empty or the file
+// doesn't declare a top-level "sections" key - i.e. it is the original
+// flat toc.yaml, which LoadModel keeps handling as before.
+func LoadTOCSections(path string) ([]TOCSection, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonRaw, err := yaml.YAMLToJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(jsonRaw, &probe); err != nil || probe["sections"] == nil {
+		// Not the section schema: leave it to LoadModel's flat toc.yaml handling.
+		return nil, nil
+	}
+
+	if err := validateTOC(jsonRaw); err != nil {
+		return nil, fmt.Errorf("validating %s: %w", path, err)
+	}
+
+	var doc tocDocument
+	if err := json.Unmarshal(jsonRaw, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return doc.Sections, nil
+}
+
+func validateTOC(jsonRaw []byte) error {
+	schema, err := tocSchemaFS.ReadFile("toc.schema.json")
+	if err != nil {
+		return err
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schema), gojsonschema.NewBytesLoader(jsonRaw))
+	if err != nil {
+		return err
+	}
+	if !result.Valid() {
+		msgs := make([]string, 0, len(result.Errors()))
+		for _, e := range result.Errors() {
+			msgs = append(msgs, e.String())
+		}
+		return fmt.Errorf("%s", strings.Join(msgs, "; "))
+	}
+
+	return nil
+}